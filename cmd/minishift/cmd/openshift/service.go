@@ -17,9 +17,15 @@ limitations under the License.
 package openshift
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"os/exec"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/docker/machine/libmachine"
 	"github.com/minishift/minishift/cmd/minishift/cmd/util"
@@ -30,28 +36,91 @@ import (
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultHTTPFormat  = "http://{{.IP}}:{{.Port}}"
+	defaultHTTPSFormat = "https://{{.IP}}:{{.Port}}"
+
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
 )
 
 var (
-	namespace   string
-	inbrowser   bool
-	https       bool
-	url         bool
-	serviceName string
+	namespace      string
+	inbrowser      bool
+	https          bool
+	url            bool
+	all            bool
+	format         string
+	wait           int
+	interval       int
+	output         string
+	serviceName    string
+	serviceURLTmpl *template.Template
 )
 
+// serviceURLData is the set of fields exposed to the --format template.
+// The template is only applied to services resolved via NodePort; a service
+// with a Route is reported by its first route URL as is. Path is never set,
+// so it always renders as an empty string rather than failing template
+// execution for formats that reference it (e.g. reverse-proxy style
+// "{{.Scheme}}://{{.Name}}.{{.Namespace}}/{{.Path}}").
+type serviceURLData struct {
+	IP        string
+	Port      string
+	NodePort  string
+	Name      string
+	Namespace string
+	Scheme    string
+	Path      string
+}
+
 // serviceCmd represents the service command
 var serviceCmd = &cobra.Command{
 	Use:   "service [flags] SERVICE",
 	Short: "Opens the URL for the specified service in the browser or prints it to the console.",
 	Long:  `Opens the URL for the specified service and namespace in the default browser or prints it to the console. If no namespace is provided, 'default' is assumed.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if format == "" {
+			format = defaultHTTPFormat
+			if https {
+				format = defaultHTTPSFormat
+			}
+		}
+
+		tmpl, err := template.New("url").Parse(format)
+		if err != nil {
+			atexit.ExitWithMessage(1, fmt.Sprintf("Error parsing --format template: %s", err.Error()))
+		}
+		serviceURLTmpl = tmpl
+
+		switch output {
+		case outputTable, outputJSON, outputYAML:
+		default:
+			atexit.ExitWithMessage(1, fmt.Sprintf("Invalid output format '%s', must be one of: table, json, yaml.", output))
+		}
+
+		if inbrowser && os.Getenv("MINISHIFT_NO_BROWSER") == "1" {
+			inbrowser = false
+			url = true
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		api := libmachine.NewClient(state.InstanceDirs.Home, state.InstanceDirs.Certs)
 		defer api.Close()
 
 		util.ExitIfUndefined(api, constants.MachineName)
 
-		if len(args) == 0 || len(args) > 1 {
+		if len(args) > 1 {
+			atexit.ExitWithMessage(1, "You must specify the name of the service.")
+		}
+		if len(args) == 1 && all {
+			atexit.ExitWithMessage(1, "You cannot use --all together with a service name.")
+		}
+		if len(args) == 0 && !all {
 			atexit.ExitWithMessage(1, "You must specify the name of the service.")
 		}
 
@@ -67,13 +136,19 @@ var serviceCmd = &cobra.Command{
 			atexit.ExitWithMessage(1, fmt.Sprintf("Error getting IP: %s", err.Error()))
 		}
 
-		serviceName = args[0]
+		if len(args) == 1 {
+			serviceName = args[0]
+		}
 
 		services, err := openshift.GetServices(namespace)
 		if err != nil {
 			atexit.ExitWithMessage(1, err.Error())
 		}
 
+		services = matchingServices(services)
+
+		waitForServices(services, ip)
+
 		if url {
 			stdOutURL(services, ip)
 		}
@@ -88,25 +163,25 @@ var serviceCmd = &cobra.Command{
 
 func init() {
 	serviceCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "The namespace of the service.")
-	serviceCmd.Flags().BoolVar(&inbrowser, "in-browser", false, "Access the service in the default browser.")
+	serviceCmd.Flags().BoolVar(&inbrowser, "in-browser", false, "Access the service in the default browser. Ignored (falls back to --url) when MINISHIFT_NO_BROWSER=1 is set.")
 	serviceCmd.Flags().BoolVarP(&url, "url", "u", false, "Print the service URL to standard output.")
 	serviceCmd.Flags().BoolVar(&https, "https", false, "Access the service with HTTPS instead of HTTP.")
+	serviceCmd.Flags().BoolVar(&all, "all", false, "Print/open the URL for every service in the namespace (or all namespaces if -n is omitted), instead of a single named service.")
+	serviceCmd.Flags().StringVar(&format, "format", "", fmt.Sprintf("Format to output the NodePort service URL in; services with a route use the route URL unchanged. This is a go template and can use the fields .IP, .Port, .NodePort, .Name, .Namespace, .Scheme and .Path (always empty). Defaults to %q (or %q with --https).", defaultHTTPFormat, defaultHTTPSFormat))
+	serviceCmd.Flags().IntVar(&wait, "wait", 0, "Number of seconds to wait for the service to respond before giving up. 0 disables waiting and resolves the URL immediately.")
+	serviceCmd.Flags().IntVar(&interval, "interval", 2, "Number of seconds to wait between readiness checks when --wait is set.")
+	serviceCmd.Flags().StringVarP(&output, "output", "o", outputTable, "Output format. One of: table, json, yaml.")
 	OpenShiftCmd.AddCommand(serviceCmd)
 }
 
-func openInBrowser(services []openshift.Service, ip string) {
-	serviceURL := getServiceURL(services, ip)
-	fmt.Fprintln(os.Stdout, "Opening the route/NodePort "+serviceURL+" in the default browser...")
-	browser.OpenURL(serviceURL)
-}
-
-func stdOutURL(services []openshift.Service, ip string) {
-	serviceURL := getServiceURL(services, ip)
-	fmt.Fprintln(os.Stdout, serviceURL)
-}
+// matchingServices filters services down to the ones the command was invoked
+// for: every service when --all was given, otherwise only the services
+// named on the command line.
+func matchingServices(services []openshift.Service) []openshift.Service {
+	if all {
+		return services
+	}
 
-func getServiceURL(services []openshift.Service, ip string) string {
-	serviceURL := ""
 	namespaceList := isServiceInMultipleNamespace(services, serviceName)
 	if len(namespaceList) == 0 {
 		atexit.ExitWithMessage(1, fmt.Sprintf("Service '%s' does not exist", serviceName))
@@ -116,26 +191,173 @@ func getServiceURL(services []openshift.Service, ip string) string {
 		atexit.ExitWithMessage(1, fmt.Sprintf("Service '%s' exists in multiple namespaces (%s), you need to chose a specific namespace using -n <namespace>.", serviceName, namespaces))
 	}
 
+	matches := []openshift.Service{}
 	for _, service := range services {
 		if service.Name == serviceName {
-			if service.URL != nil {
-				serviceURL = service.URL[0]
-				return serviceURL
-
-			} else if service.NodePort != "" {
-				nodePortURL := fmt.Sprintf("%s:%s", ip, service.NodePort)
-				urlScheme := "http://"
-				if https {
-					urlScheme = "https://"
-				}
-				serviceURL = urlScheme + nodePortURL
-				return serviceURL
-			} else {
-				atexit.ExitWithMessage(1, fmt.Sprintf("Service '%s' in namespace '%s' does not have route associated which can be opened in the browser.", service.Name, service.Namespace))
+			matches = append(matches, service)
+		}
+	}
+	return matches
+}
+
+func openInBrowser(services []openshift.Service, ip string) {
+	for _, service := range services {
+		serviceURL, err := getServiceURL(service, ip)
+		if err != nil {
+			if !all {
+				atexit.ExitWithMessage(1, err.Error())
 			}
+			fmt.Fprintln(os.Stderr, err.Error())
+			continue
+		}
+		fmt.Fprintln(os.Stdout, "Opening the route/NodePort "+serviceURL+" in the default browser...")
+		if err := openURLInBrowser(serviceURL); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Sprintf("Could not open a browser (%s), printing the URL instead:", err.Error()))
+			fmt.Fprintln(os.Stdout, serviceURL)
 		}
 	}
-	return serviceURL
+}
+
+// openURLInBrowser opens serviceURL in a browser. It honors the BROWSER
+// env var as an explicit opener command and uses github.com/pkg/browser only
+// when BROWSER is unset; if the BROWSER command cannot be started, its error
+// is returned without trying github.com/pkg/browser. Errors are returned
+// (rather than exiting) so callers can recover by printing the URL instead -
+// this keeps --in-browser usable over SSH and in headless CI.
+func openURLInBrowser(serviceURL string) error {
+	if parts := strings.Fields(os.Getenv("BROWSER")); len(parts) > 0 {
+		cmd := exec.Command(parts[0], append(parts[1:], serviceURL)...)
+		return cmd.Start()
+	}
+
+	return browser.OpenURL(serviceURL)
+}
+
+func stdOutURL(services []openshift.Service, ip string) {
+	for _, service := range services {
+		serviceURL, err := getServiceURL(service, ip)
+		if err != nil {
+			if !all {
+				atexit.ExitWithMessage(1, err.Error())
+			}
+			fmt.Fprintln(os.Stderr, err.Error())
+			continue
+		}
+		fmt.Fprintln(os.Stdout, serviceURL)
+	}
+}
+
+// getServiceURL resolves the URL a single service should be reached at. It
+// returns an error, rather than exiting, when the service has neither a
+// Route nor a NodePort, so that callers iterating over multiple services
+// (--all) can skip it with a warning instead of aborting the whole command.
+func getServiceURL(service openshift.Service, ip string) (string, error) {
+	if service.URL != nil {
+		return service.URL[0], nil
+	}
+	if service.NodePort != "" {
+		scheme := "http"
+		if https {
+			scheme = "https"
+		}
+		data := serviceURLData{
+			IP:        ip,
+			Port:      service.NodePort,
+			NodePort:  service.NodePort,
+			Name:      service.Name,
+			Namespace: service.Namespace,
+			Scheme:    scheme,
+		}
+		var buf bytes.Buffer
+		if err := serviceURLTmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("Error rendering --format template for service '%s': %s", service.Name, err.Error())
+		}
+		return buf.String(), nil
+	}
+	return "", fmt.Errorf("Service '%s' in namespace '%s' does not have route associated which can be opened in the browser.", service.Name, service.Namespace)
+}
+
+// waitForServices polls each service until it resolves to a ready URL or the
+// shared --wait deadline elapses, so that --url, --in-browser and the
+// default table output don't lose the race with a service that was only
+// just created and has no Route/NodePort endpoint responding yet. Each retry
+// re-fetches the service from openshift.GetServices, since the Route/
+// NodePort we're waiting on is attached to the service after the fact and
+// won't show up by re-probing the snapshot taken before --wait started. The
+// refreshed service is written back into services[i] so downstream
+// rendering (stdOutURL/openInBrowser/printToStdOut) sees the resolved data
+// instead of the pre-wait snapshot. All services share a single --wait
+// deadline rather than each getting --wait seconds of their own, so --all
+// with N services waits about --wait in total, not N*--wait. The deadline is
+// checked only between polls: the last sleep can run past it by up to
+// --interval, and each service still unresolved at the deadline is probed
+// once more (up to the 5s HTTP timeout) before it is given up.
+func waitForServices(services []openshift.Service, ip string) {
+	if wait <= 0 {
+		return
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+
+	for i := range services {
+		current := services[i]
+		for {
+			serviceURL, err := getServiceURL(current, ip)
+			if err == nil && isServiceReady(client, serviceURL) {
+				services[i] = current
+				break
+			}
+
+			if time.Now().After(deadline) {
+				fmt.Fprintf(os.Stderr, "Warning: timed out after %ds waiting for service '%s' to respond.\n", wait, services[i].Name)
+				services[i] = current
+				break
+			}
+
+			time.Sleep(time.Duration(interval) * time.Second)
+
+			if refreshed, ok := refetchService(current.Namespace, current.Name); ok {
+				current = refreshed
+			}
+		}
+	}
+}
+
+// isServiceReady probes serviceURL over HTTP(S) and reports a non-5xx
+// response as ready. --format templates can produce non-HTTP schemes (tcp://,
+// jdbc:, ...), which net/http can't probe, so for those we can only treat a
+// successfully resolved URL as ready.
+func isServiceReady(client *http.Client, serviceURL string) bool {
+	scheme := ""
+	if idx := strings.Index(serviceURL, "://"); idx != -1 {
+		scheme = strings.ToLower(serviceURL[:idx])
+	}
+	if scheme != "http" && scheme != "https" {
+		return true
+	}
+
+	resp, err := client.Get(serviceURL)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode < 500
+}
+
+// refetchService re-fetches the given namespace/name service from the API so
+// that waitForServices observes a Route/NodePort attached mid-wait.
+func refetchService(namespace, name string) (openshift.Service, bool) {
+	services, err := openshift.GetServices(namespace)
+	if err != nil {
+		return openshift.Service{}, false
+	}
+	for _, service := range services {
+		if service.Name == name && service.Namespace == namespace {
+			return service, true
+		}
+	}
+	return openshift.Service{}, false
 }
 
 func isServiceInMultipleNamespace(services []openshift.Service, serviceName string) []string {
@@ -149,23 +371,30 @@ func isServiceInMultipleNamespace(services []openshift.Service, serviceName stri
 }
 
 func printToStdOut(services []openshift.Service, ip string) {
+	switch output {
+	case outputJSON, outputYAML:
+		printStructuredOutput(services, ip)
+	default:
+		printTable(services, ip)
+	}
+}
+
+func printTable(services []openshift.Service, ip string) {
 	var data [][]string
-	var urls, weights string
 
 	for _, service := range services {
-		if service.Name == serviceName {
-			nodePortURL := service.NodePort
-			if nodePortURL != "" {
-				nodePortURL = fmt.Sprintf("%s:%s", ip, nodePortURL)
-			}
-			if service.URL != nil {
-				urls = strings.Join(service.URL, "\n")
-			}
-			if service.Weight != nil {
-				weights = strings.Join(service.Weight, "\n")
-			}
-			data = append(data, []string{service.Namespace, service.Name, nodePortURL, urls, weights})
+		var urls, weights string
+		nodePortURL := service.NodePort
+		if nodePortURL != "" {
+			nodePortURL = fmt.Sprintf("%s:%s", ip, nodePortURL)
 		}
+		if service.URL != nil {
+			urls = strings.Join(service.URL, "\n")
+		}
+		if service.Weight != nil {
+			weights = strings.Join(service.Weight, "\n")
+		}
+		data = append(data, []string{service.Namespace, service.Name, nodePortURL, urls, weights})
 	}
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Namespace", "Name", "NodePort", "Route-URL", "Weight"})
@@ -174,3 +403,46 @@ func printToStdOut(services []openshift.Service, ip string) {
 	table.AppendBulk(data)
 	table.Render()
 }
+
+// buildServiceInfo resolves service into its serializable ServiceInfo,
+// including the fully-resolved URL(s) it would be reached at.
+func buildServiceInfo(service openshift.Service, ip string) openshift.ServiceInfo {
+	info := openshift.ServiceInfo{
+		Namespace: service.Namespace,
+		Name:      service.Name,
+		NodePort:  service.NodePort,
+		Urls:      service.URL,
+		Weights:   service.Weight,
+	}
+
+	if service.URL != nil {
+		info.ServiceURLs = service.URL
+	} else if serviceURL, err := getServiceURL(service, ip); err == nil {
+		info.ServiceURLs = []string{serviceURL}
+	} else {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+
+	return info
+}
+
+func printStructuredOutput(services []openshift.Service, ip string) {
+	infos := make([]openshift.ServiceInfo, 0, len(services))
+	for _, service := range services {
+		infos = append(infos, buildServiceInfo(service, ip))
+	}
+
+	var (
+		out []byte
+		err error
+	)
+	if output == outputJSON {
+		out, err = json.MarshalIndent(infos, "", "  ")
+	} else {
+		out, err = yaml.Marshal(infos)
+	}
+	if err != nil {
+		atexit.ExitWithMessage(1, fmt.Sprintf("Error encoding service output: %s", err.Error()))
+	}
+	fmt.Fprintln(os.Stdout, string(out))
+}
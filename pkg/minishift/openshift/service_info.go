@@ -0,0 +1,29 @@
+/*
+Copyright (C) 2016 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openshift
+
+// ServiceInfo is the structured, serializable representation of a Service
+// and its resolved URL(s), used by consumers such as 'minishift openshift
+// service -o json|yaml' that need a scriptable form of the service table.
+type ServiceInfo struct {
+	Namespace   string   `json:"namespace" yaml:"namespace"`
+	Name        string   `json:"name" yaml:"name"`
+	NodePort    string   `json:"nodePort" yaml:"nodePort"`
+	Urls        []string `json:"urls,omitempty" yaml:"urls,omitempty"`
+	Weights     []string `json:"weights,omitempty" yaml:"weights,omitempty"`
+	ServiceURLs []string `json:"serviceUrls" yaml:"serviceUrls"`
+}